@@ -0,0 +1,338 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericmutator
+
+import (
+	"context"
+
+	"github.com/Masterminds/semver"
+	"github.com/coreos/go-systemd/v22/unit"
+	druidv1alpha1 "github.com/gardener/etcd-druid/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kubeletconfigv1beta1 "k8s.io/kubelet/config/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	extensionscontextwebhook "github.com/gardener/gardener/extensions/pkg/webhook/context"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+)
+
+// NoopEnsurer can be embedded into an Ensurer implementation to get default no-op implementations for methods
+// that the embedding ensurer does not care about.
+type NoopEnsurer struct{}
+
+var _ Ensurer = NoopEnsurer{}
+
+// EnsureKubeAPIServerService implements Ensurer.
+func (NoopEnsurer) EnsureKubeAPIServerService(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *corev1.Service) error {
+	return nil
+}
+
+// EnsureKubeAPIServerDeployment implements Ensurer.
+func (NoopEnsurer) EnsureKubeAPIServerDeployment(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *appsv1.Deployment) error {
+	return nil
+}
+
+// EnsureKubeControllerManagerDeployment implements Ensurer.
+func (NoopEnsurer) EnsureKubeControllerManagerDeployment(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *appsv1.Deployment) error {
+	return nil
+}
+
+// EnsureKubeSchedulerDeployment implements Ensurer.
+func (NoopEnsurer) EnsureKubeSchedulerDeployment(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *appsv1.Deployment) error {
+	return nil
+}
+
+// EnsureClusterAutoscalerDeployment implements Ensurer.
+func (NoopEnsurer) EnsureClusterAutoscalerDeployment(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *appsv1.Deployment) error {
+	return nil
+}
+
+// EnsureETCD implements Ensurer.
+func (NoopEnsurer) EnsureETCD(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *druidv1alpha1.Etcd) error {
+	return nil
+}
+
+// EnsureVPNSeedServerDeployment implements Ensurer.
+func (NoopEnsurer) EnsureVPNSeedServerDeployment(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *appsv1.Deployment) error {
+	return nil
+}
+
+// EnsureKubeletServiceUnitOptions implements Ensurer.
+func (NoopEnsurer) EnsureKubeletServiceUnitOptions(_ context.Context, _ extensionscontextwebhook.GardenContext, _ *semver.Version, new, _ []*unit.UnitOption) ([]*unit.UnitOption, error) {
+	return new, nil
+}
+
+// EnsureKubeletConfiguration implements Ensurer.
+func (NoopEnsurer) EnsureKubeletConfiguration(_ context.Context, _ extensionscontextwebhook.GardenContext, _ *semver.Version, _, _ *kubeletconfigv1beta1.KubeletConfiguration) error {
+	return nil
+}
+
+// ShouldProvisionKubeletCloudProviderConfig implements Ensurer.
+func (NoopEnsurer) ShouldProvisionKubeletCloudProviderConfig(_ context.Context, _ extensionscontextwebhook.GardenContext, _ *semver.Version) bool {
+	return false
+}
+
+// EnsureKubeletCloudProviderConfig implements Ensurer.
+func (NoopEnsurer) EnsureKubeletCloudProviderConfig(_ context.Context, _ extensionscontextwebhook.GardenContext, _ *semver.Version, _ *string, _ string) error {
+	return nil
+}
+
+// EnsureKubernetesGeneralConfiguration implements Ensurer.
+func (NoopEnsurer) EnsureKubernetesGeneralConfiguration(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *string) error {
+	return nil
+}
+
+// EnsureAdditionalUnits implements Ensurer.
+func (NoopEnsurer) EnsureAdditionalUnits(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *[]extensionsv1alpha1.Unit) error {
+	return nil
+}
+
+// EnsureAdditionalFiles implements Ensurer.
+func (NoopEnsurer) EnsureAdditionalFiles(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *[]extensionsv1alpha1.File) error {
+	return nil
+}
+
+// EnsureProvisionOSCFiles implements Ensurer.
+func (NoopEnsurer) EnsureProvisionOSCFiles(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *[]extensionsv1alpha1.File) error {
+	return nil
+}
+
+// EnsureProvisionOSCUnits implements Ensurer.
+func (NoopEnsurer) EnsureProvisionOSCUnits(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *[]extensionsv1alpha1.Unit) error {
+	return nil
+}
+
+// EnsureDaemonSet implements Ensurer.
+func (NoopEnsurer) EnsureDaemonSet(_ context.Context, _ extensionscontextwebhook.GardenContext, _ string, _, _ *appsv1.DaemonSet) error {
+	return nil
+}
+
+// EnsureStatefulSet implements Ensurer.
+func (NoopEnsurer) EnsureStatefulSet(_ context.Context, _ extensionscontextwebhook.GardenContext, _ string, _, _ *appsv1.StatefulSet) error {
+	return nil
+}
+
+// EnsureObject implements Ensurer.
+func (NoopEnsurer) EnsureObject(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ client.Object) error {
+	return nil
+}
+
+// chainEnsurer is an Ensurer that invokes a list of Ensurers in order, short-circuiting on the first error.
+// Each ensurer in the chain observes the mutations applied by the ensurers before it, since "new" is mutated
+// in place.
+type chainEnsurer struct {
+	ensurers []Ensurer
+}
+
+// NewChainEnsurer creates an Ensurer that invokes the given ensurers in order for every Ensure* method,
+// short-circuiting as soon as one of them returns an error. It allows an extension to compose several small,
+// orthogonal ensurers (e.g. one for OIDC webhook flags, one for audit log flags) instead of having to
+// implement one monolithic Ensurer.
+func NewChainEnsurer(ensurers ...Ensurer) Ensurer {
+	return &chainEnsurer{ensurers: ensurers}
+}
+
+// EnsureKubeAPIServerService implements Ensurer.
+func (c *chainEnsurer) EnsureKubeAPIServerService(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *corev1.Service) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureKubeAPIServerService(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureKubeAPIServerDeployment implements Ensurer.
+func (c *chainEnsurer) EnsureKubeAPIServerDeployment(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *appsv1.Deployment) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureKubeAPIServerDeployment(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureKubeControllerManagerDeployment implements Ensurer.
+func (c *chainEnsurer) EnsureKubeControllerManagerDeployment(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *appsv1.Deployment) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureKubeControllerManagerDeployment(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureKubeSchedulerDeployment implements Ensurer.
+func (c *chainEnsurer) EnsureKubeSchedulerDeployment(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *appsv1.Deployment) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureKubeSchedulerDeployment(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureClusterAutoscalerDeployment implements Ensurer.
+func (c *chainEnsurer) EnsureClusterAutoscalerDeployment(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *appsv1.Deployment) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureClusterAutoscalerDeployment(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureETCD implements Ensurer.
+func (c *chainEnsurer) EnsureETCD(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *druidv1alpha1.Etcd) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureETCD(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureVPNSeedServerDeployment implements Ensurer.
+func (c *chainEnsurer) EnsureVPNSeedServerDeployment(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *appsv1.Deployment) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureVPNSeedServerDeployment(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureKubeletServiceUnitOptions implements Ensurer.
+func (c *chainEnsurer) EnsureKubeletServiceUnitOptions(ctx context.Context, gctx extensionscontextwebhook.GardenContext, kubeletVersion *semver.Version, new, old []*unit.UnitOption) ([]*unit.UnitOption, error) {
+	var err error
+	for _, ensurer := range c.ensurers {
+		if new, err = ensurer.EnsureKubeletServiceUnitOptions(ctx, gctx, kubeletVersion, new, old); err != nil {
+			return nil, err
+		}
+	}
+	return new, nil
+}
+
+// EnsureKubeletConfiguration implements Ensurer.
+func (c *chainEnsurer) EnsureKubeletConfiguration(ctx context.Context, gctx extensionscontextwebhook.GardenContext, kubeletVersion *semver.Version, new, old *kubeletconfigv1beta1.KubeletConfiguration) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureKubeletConfiguration(ctx, gctx, kubeletVersion, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShouldProvisionKubeletCloudProviderConfig implements Ensurer using OR semantics: it returns true if any
+// ensurer in the chain requires the cloud provider config to be provisioned.
+func (c *chainEnsurer) ShouldProvisionKubeletCloudProviderConfig(ctx context.Context, gctx extensionscontextwebhook.GardenContext, kubeletVersion *semver.Version) bool {
+	for _, ensurer := range c.ensurers {
+		if ensurer.ShouldProvisionKubeletCloudProviderConfig(ctx, gctx, kubeletVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureKubeletCloudProviderConfig implements Ensurer.
+func (c *chainEnsurer) EnsureKubeletCloudProviderConfig(ctx context.Context, gctx extensionscontextwebhook.GardenContext, kubeletVersion *semver.Version, configContent *string, namespace string) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureKubeletCloudProviderConfig(ctx, gctx, kubeletVersion, configContent, namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureKubernetesGeneralConfiguration implements Ensurer.
+func (c *chainEnsurer) EnsureKubernetesGeneralConfiguration(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *string) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureKubernetesGeneralConfiguration(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureAdditionalUnits implements Ensurer.
+func (c *chainEnsurer) EnsureAdditionalUnits(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *[]extensionsv1alpha1.Unit) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureAdditionalUnits(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureAdditionalFiles implements Ensurer.
+func (c *chainEnsurer) EnsureAdditionalFiles(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *[]extensionsv1alpha1.File) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureAdditionalFiles(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureProvisionOSCFiles implements Ensurer.
+func (c *chainEnsurer) EnsureProvisionOSCFiles(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *[]extensionsv1alpha1.File) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureProvisionOSCFiles(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureProvisionOSCUnits implements Ensurer.
+func (c *chainEnsurer) EnsureProvisionOSCUnits(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *[]extensionsv1alpha1.Unit) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureProvisionOSCUnits(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureDaemonSet implements Ensurer.
+func (c *chainEnsurer) EnsureDaemonSet(ctx context.Context, gctx extensionscontextwebhook.GardenContext, name string, new, old *appsv1.DaemonSet) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureDaemonSet(ctx, gctx, name, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureStatefulSet implements Ensurer.
+func (c *chainEnsurer) EnsureStatefulSet(ctx context.Context, gctx extensionscontextwebhook.GardenContext, name string, new, old *appsv1.StatefulSet) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureStatefulSet(ctx, gctx, name, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureObject implements Ensurer.
+func (c *chainEnsurer) EnsureObject(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old client.Object) error {
+	for _, ensurer := range c.ensurers {
+		if err := ensurer.EnsureObject(ctx, gctx, new, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
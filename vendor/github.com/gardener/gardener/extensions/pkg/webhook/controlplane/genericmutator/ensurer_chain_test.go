@@ -0,0 +1,121 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericmutator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	extensionscontextwebhook "github.com/gardener/gardener/extensions/pkg/webhook/context"
+)
+
+// recordingEnsurer appends its name to a shared slice and optionally sets a label on the service, so tests
+// can observe both call order and whether an ensurer saw mutations applied by a previous one in the chain.
+type recordingEnsurer struct {
+	NoopEnsurer
+	name    string
+	calls   *[]string
+	failFor string
+	setKey  string
+}
+
+func (e *recordingEnsurer) EnsureKubeAPIServerService(_ context.Context, _ extensionscontextwebhook.GardenContext, new, _ *corev1.Service) error {
+	*e.calls = append(*e.calls, e.name)
+	if e.failFor == e.name {
+		return errors.New("boom")
+	}
+	if e.setKey != "" {
+		if new.Labels == nil {
+			new.Labels = map[string]string{}
+		}
+		new.Labels[e.setKey] = e.name
+	}
+	return nil
+}
+
+func TestChainEnsurerOrderIsDeterministic(t *testing.T) {
+	var calls []string
+	chain := NewChainEnsurer(
+		&recordingEnsurer{name: "first", calls: &calls},
+		&recordingEnsurer{name: "second", calls: &calls},
+		&recordingEnsurer{name: "third", calls: &calls},
+	)
+
+	svc := &corev1.Service{}
+	if err := chain.EnsureKubeAPIServerService(context.Background(), nil, svc, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"first", "second", "third"}
+	if len(calls) != len(want) {
+		t.Fatalf("got %v calls, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("got order %v, want %v", calls, want)
+		}
+	}
+}
+
+func TestChainEnsurerShortCircuitsOnError(t *testing.T) {
+	var calls []string
+	chain := NewChainEnsurer(
+		&recordingEnsurer{name: "first", calls: &calls},
+		&recordingEnsurer{name: "second", calls: &calls, failFor: "second"},
+		&recordingEnsurer{name: "third", calls: &calls},
+	)
+
+	svc := &corev1.Service{}
+	if err := chain.EnsureKubeAPIServerService(context.Background(), nil, svc, nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	want := []string{"first", "second"}
+	if len(calls) != len(want) {
+		t.Fatalf("got %v calls, want %v (third should not have run)", calls, want)
+	}
+}
+
+func TestChainEnsurerLaterEnsurerSeesEarlierMutation(t *testing.T) {
+	var calls []string
+	chain := NewChainEnsurer(
+		&recordingEnsurer{name: "first", calls: &calls, setKey: "set-by"},
+		&observingEnsurer{},
+	)
+
+	svc := &corev1.Service{}
+	if err := chain.EnsureKubeAPIServerService(context.Background(), nil, svc, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := svc.Labels["set-by"]; got != "first" {
+		t.Fatalf("expected second ensurer to observe label set by first, got %q", got)
+	}
+}
+
+// observingEnsurer asserts that it sees the mutation applied by a preceding ensurer.
+type observingEnsurer struct {
+	NoopEnsurer
+}
+
+func (observingEnsurer) EnsureKubeAPIServerService(_ context.Context, _ extensionscontextwebhook.GardenContext, new, _ *corev1.Service) error {
+	if new.Labels["set-by"] != "first" {
+		return errors.New("did not observe mutation from earlier ensurer in chain")
+	}
+	return nil
+}
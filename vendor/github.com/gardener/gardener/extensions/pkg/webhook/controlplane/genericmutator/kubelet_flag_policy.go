@@ -0,0 +1,114 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericmutator
+
+import (
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/coreos/go-systemd/v22/unit"
+
+	extensionswebhook "github.com/gardener/gardener/extensions/pkg/webhook"
+)
+
+// kubeletExecStartSeparator is the separator extensionswebhook.SerializeCommandLine uses between flags of
+// the kubelet.service unit's ExecStart command line, after the binary path itself.
+const kubeletExecStartSeparator = " \\\n    "
+
+// KubeletFlagPolicy declares, for a set of kubelet command-line flags, the Kubernetes version ranges in
+// which they are valid as well as renames that should be applied. It allows the generic mutator to drop or
+// rewrite deprecated kubelet flags on behalf of a provider instead of every provider ensurer re-implementing
+// the same version switches.
+type KubeletFlagPolicy struct {
+	// ValidIn maps a kubelet flag name (e.g. "--pod-eviction-timeout") to the version constraint in which the
+	// flag is valid. Flags whose constraint does not match the effective kubelet version are removed from the
+	// kubelet.service unit's ExecStart command line.
+	ValidIn map[string]*semver.Constraints
+	// Renames maps a deprecated flag name to the name it should be rewritten to (e.g. "--cloud-provider" would
+	// be rewritten once the provider policy requires it). Renaming happens before the ValidIn check, so a
+	// renamed flag is validated under its new name.
+	Renames map[string]string
+	// ValueRewrites maps a flag name to a version-gated rewrite of its value (e.g. "--cloud-provider" is
+	// rewritten to "external" from 1.23+). Rewrites are applied after Renames, so they key on the flag's new
+	// name, and before the ValidIn check.
+	ValueRewrites map[string]KubeletFlagValueRewrite
+}
+
+// KubeletFlagValueRewrite rewrites a kubelet flag's value once the effective kubelet version matches In. An
+// empty Value turns the flag into a bare boolean flag, dropping any "=value" suffix.
+type KubeletFlagValueRewrite struct {
+	// In is the version constraint in which this rewrite applies.
+	In *semver.Constraints
+	// Value is the value the flag is rewritten to.
+	Value string
+}
+
+// apply rewrites renamed flags and values, and strips flags that are not valid for the given kubelet version,
+// from the ExecStart command line of the kubelet.service unit.
+func (p KubeletFlagPolicy) apply(kubeletVersion *semver.Version, opts []*unit.UnitOption) ([]*unit.UnitOption, error) {
+	if len(p.ValidIn) == 0 && len(p.Renames) == 0 && len(p.ValueRewrites) == 0 {
+		return opts, nil
+	}
+
+	execStart := extensionswebhook.UnitOptionWithSectionAndName(opts, "Service", "ExecStart")
+	if execStart == nil {
+		return opts, nil
+	}
+
+	command := extensionswebhook.DeserializeCommandLine(execStart.Value)
+	if len(command) == 0 {
+		return opts, nil
+	}
+	binary, args := command[0], command[1:]
+
+	filtered := make([]string, 0, len(args))
+	for _, arg := range args {
+		name, value, hasValue := splitFlag(arg)
+
+		if renamed, ok := p.Renames[name]; ok {
+			name = renamed
+		}
+
+		// A nil constraint (e.g. a policy entry built from an ignored semver.NewConstraint error) makes its
+		// policy entry a no-op rather than a panic in the mutating webhook: a rewrite with a nil In is never
+		// applied, and a flag with a nil ValidIn constraint is kept.
+		if rewrite, ok := p.ValueRewrites[name]; ok && rewrite.In != nil && rewrite.In.Check(kubeletVersion) {
+			value = rewrite.Value
+			hasValue = value != ""
+		}
+
+		if constraint, ok := p.ValidIn[name]; ok && constraint != nil && !constraint.Check(kubeletVersion) {
+			continue
+		}
+
+		if hasValue {
+			filtered = append(filtered, name+"="+value)
+		} else {
+			filtered = append(filtered, name)
+		}
+	}
+
+	execStart.Value = extensionswebhook.SerializeCommandLine(append([]string{binary}, filtered...), 1, kubeletExecStartSeparator)
+	return opts, nil
+}
+
+// splitFlag splits a command-line argument of the form "--flag=value" into its flag name and value. If the
+// argument does not carry a value, hasValue is false.
+func splitFlag(arg string) (name, value string, hasValue bool) {
+	if idx := strings.Index(arg, "="); idx >= 0 {
+		return arg[:idx], arg[idx+1:], true
+	}
+	return arg, "", false
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericmutator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/coreos/go-systemd/v22/unit"
+
+	extensionswebhook "github.com/gardener/gardener/extensions/pkg/webhook"
+)
+
+func execStartOpts(cmdline string) []*unit.UnitOption {
+	return []*unit.UnitOption{
+		{Section: "Service", Name: "ExecStart", Value: cmdline},
+	}
+}
+
+func applyAndGetExecStart(t *testing.T, policy KubeletFlagPolicy, kubeletVersion, cmdline string) string {
+	t.Helper()
+
+	opts, err := policy.apply(semver.MustParse(kubeletVersion), execStartOpts(cmdline))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	execStart := extensionswebhook.UnitOptionWithSectionAndName(opts, "Service", "ExecStart")
+	if execStart == nil {
+		t.Fatal("ExecStart option missing after apply")
+	}
+	return execStart.Value
+}
+
+func TestKubeletFlagPolicyRemovesFlagOutsideValidRange(t *testing.T) {
+	policy := KubeletFlagPolicy{
+		ValidIn: map[string]*semver.Constraints{
+			"--pod-eviction-timeout": mustConstraint(t, "< 1.23"),
+		},
+	}
+
+	got := applyAndGetExecStart(t, policy, "1.23.0", "/usr/bin/kubelet --pod-eviction-timeout=5m0s --v=2")
+
+	if strings.Contains(got, "--pod-eviction-timeout") {
+		t.Fatalf("expected --pod-eviction-timeout to be removed for 1.23, got %q", got)
+	}
+	if !strings.Contains(got, "--v=2") {
+		t.Fatalf("expected unrelated flag --v=2 to be kept, got %q", got)
+	}
+}
+
+func TestKubeletFlagPolicyKeepsFlagInsideValidRange(t *testing.T) {
+	policy := KubeletFlagPolicy{
+		ValidIn: map[string]*semver.Constraints{
+			"--pod-eviction-timeout": mustConstraint(t, "< 1.23"),
+		},
+	}
+
+	got := applyAndGetExecStart(t, policy, "1.22.0", "/usr/bin/kubelet --pod-eviction-timeout=5m0s")
+
+	if !strings.Contains(got, "--pod-eviction-timeout=5m0s") {
+		t.Fatalf("expected --pod-eviction-timeout to be kept for 1.22, got %q", got)
+	}
+}
+
+func TestKubeletFlagPolicyRenamesFlagBeforeValidInCheck(t *testing.T) {
+	policy := KubeletFlagPolicy{
+		Renames: map[string]string{
+			"--network-plugin": "--container-runtime-endpoint",
+		},
+		ValidIn: map[string]*semver.Constraints{
+			"--container-runtime-endpoint": mustConstraint(t, ">= 1.24"),
+		},
+	}
+
+	got := applyAndGetExecStart(t, policy, "1.23.0", "/usr/bin/kubelet --network-plugin=cni")
+
+	if strings.Contains(got, "--network-plugin") || strings.Contains(got, "--container-runtime-endpoint") {
+		t.Fatalf("expected renamed flag to be dropped because it is invalid below 1.24, got %q", got)
+	}
+}
+
+func TestKubeletFlagPolicyRewritesValueFromVersion(t *testing.T) {
+	policy := KubeletFlagPolicy{
+		ValueRewrites: map[string]KubeletFlagValueRewrite{
+			"--cloud-provider": {In: mustConstraint(t, ">= 1.23"), Value: "external"},
+		},
+	}
+
+	before := applyAndGetExecStart(t, policy, "1.22.0", "/usr/bin/kubelet --cloud-provider=aws")
+	if !strings.Contains(before, "--cloud-provider=aws") {
+		t.Fatalf("expected --cloud-provider to be unchanged below 1.23, got %q", before)
+	}
+
+	after := applyAndGetExecStart(t, policy, "1.23.0", "/usr/bin/kubelet --cloud-provider=aws")
+	if !strings.Contains(after, "--cloud-provider=external") {
+		t.Fatalf("expected --cloud-provider to be rewritten to external at 1.23, got %q", after)
+	}
+}
+
+func TestKubeletFlagPolicyRewritesValueToEmptyDropsValue(t *testing.T) {
+	policy := KubeletFlagPolicy{
+		ValueRewrites: map[string]KubeletFlagValueRewrite{
+			"--cloud-provider": {In: mustConstraint(t, ">= 1.23"), Value: ""},
+		},
+	}
+
+	got := applyAndGetExecStart(t, policy, "1.23.0", "/usr/bin/kubelet --cloud-provider=aws")
+
+	if strings.Contains(got, "=") && strings.Contains(got, "--cloud-provider=") {
+		t.Fatalf("expected --cloud-provider to become a bare flag, got %q", got)
+	}
+	if !strings.Contains(got, "--cloud-provider") {
+		t.Fatalf("expected --cloud-provider flag to still be present, got %q", got)
+	}
+}
+
+func mustConstraint(t *testing.T, c string) *semver.Constraints {
+	t.Helper()
+	constraint, err := semver.NewConstraint(c)
+	if err != nil {
+		t.Fatalf("could not parse constraint %q: %v", c, err)
+	}
+	return constraint
+}
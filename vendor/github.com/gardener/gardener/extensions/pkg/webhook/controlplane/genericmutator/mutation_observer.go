@@ -0,0 +1,260 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericmutator
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	extensionswebhook "github.com/gardener/gardener/extensions/pkg/webhook"
+)
+
+// MutationObserver is notified about every mutation the generic mutator performs, successful or not. It
+// replaces the previous single extensionswebhook.LogMutation call and lets operators wire up richer auditing
+// (metrics, events) without touching the mutator itself.
+type MutationObserver interface {
+	// ObserveMutation is called after ensurerMethod has run against obj, whether or not it returned an error.
+	// old may be nil if the object did not exist before the mutation.
+	ObserveMutation(ctx context.Context, obj, old client.Object, ensurerMethod string, duration time.Duration, err error)
+}
+
+// WithMutationObserver configures an additional MutationObserver that is notified about every mutation
+// performed by the returned mutator, on top of the built-in logging observer. It does not replace logging;
+// use it to wire up richer auditing (metrics, events) without losing the single-line log per mutation.
+func WithMutationObserver(observer MutationObserver) MutatorOption {
+	return func(m *mutator) {
+		m.observer = &multiMutationObserver{observers: []MutationObserver{m.observer, observer}}
+	}
+}
+
+// loggingMutationObserver logs a single line per mutation via extensionswebhook.LogMutation. Mutations whose
+// ensurer returned an error are not logged. It is always invoked, in addition to any MutationObserver
+// configured via WithMutationObserver.
+type loggingMutationObserver struct {
+	mutator *mutator
+}
+
+func (o *loggingMutationObserver) ObserveMutation(_ context.Context, obj, _ client.Object, _ string, _ time.Duration, err error) {
+	if err != nil {
+		return
+	}
+	extensionswebhook.LogMutation(o.mutator.logger, obj.GetObjectKind().GroupVersionKind().Kind, obj.GetNamespace(), obj.GetName())
+}
+
+// multiMutationObserver fans a single mutation observation out to multiple MutationObservers, in order. It
+// lets WithMutationObserver add an observer without dropping the built-in logging observer.
+type multiMutationObserver struct {
+	observers []MutationObserver
+}
+
+func (o *multiMutationObserver) ObserveMutation(ctx context.Context, obj, old client.Object, ensurerMethod string, duration time.Duration, err error) {
+	for _, observer := range o.observers {
+		observer.ObserveMutation(ctx, obj, old, ensurerMethod, duration, err)
+	}
+}
+
+const (
+	mutationResultSuccess = "success"
+	mutationResultError   = "error"
+)
+
+// PrometheusMutationObserver is a MutationObserver that exposes the number and duration of mutations
+// performed by the generic mutator as Prometheus metrics.
+type PrometheusMutationObserver struct {
+	mutationsTotal    *prometheus.CounterVec
+	mutationDurations *prometheus.HistogramVec
+}
+
+// NewPrometheusMutationObserver creates a PrometheusMutationObserver and registers its collectors with the
+// given registerer.
+func NewPrometheusMutationObserver(registerer prometheus.Registerer) (*PrometheusMutationObserver, error) {
+	o := &PrometheusMutationObserver{
+		mutationsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gardener_controlplane_mutations_total",
+			Help: "Total number of controlplane object mutations performed by the generic webhook mutator.",
+		}, []string{"kind", "namespace", "name", "ensurer_method", "result"}),
+		mutationDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gardener_controlplane_mutation_duration_seconds",
+			Help:    "Duration of controlplane object mutations performed by the generic webhook mutator.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"kind", "namespace", "name", "ensurer_method"}),
+	}
+
+	if err := registerer.Register(o.mutationsTotal); err != nil {
+		return nil, err
+	}
+	if err := registerer.Register(o.mutationDurations); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// ObserveMutation implements MutationObserver.
+func (o *PrometheusMutationObserver) ObserveMutation(_ context.Context, obj, _ client.Object, ensurerMethod string, duration time.Duration, err error) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	result := mutationResultSuccess
+	if err != nil {
+		result = mutationResultError
+	}
+
+	o.mutationsTotal.WithLabelValues(kind, obj.GetNamespace(), obj.GetName(), ensurerMethod, result).Inc()
+	o.mutationDurations.WithLabelValues(kind, obj.GetNamespace(), obj.GetName(), ensurerMethod).Observe(duration.Seconds())
+}
+
+// EventMutationObserver is a MutationObserver that records a Kubernetes event on the mutated object,
+// carrying a compact, size-bounded JSON diff of what the mutator changed.
+type EventMutationObserver struct {
+	recorder     record.EventRecorder
+	maxDiffBytes int
+}
+
+// NewEventMutationObserver creates an EventMutationObserver that records events via the given recorder. Diffs
+// are truncated to maxDiffBytes; a value <= 0 falls back to a sane default of 1024 bytes.
+func NewEventMutationObserver(recorder record.EventRecorder, maxDiffBytes int) *EventMutationObserver {
+	if maxDiffBytes <= 0 {
+		maxDiffBytes = 1024
+	}
+	return &EventMutationObserver{recorder: recorder, maxDiffBytes: maxDiffBytes}
+}
+
+// ObserveMutation implements MutationObserver.
+func (o *EventMutationObserver) ObserveMutation(_ context.Context, obj, old client.Object, ensurerMethod string, _ time.Duration, err error) {
+	if err != nil {
+		o.recorder.Eventf(obj, corev1.EventTypeWarning, "MutationFailed", "%s failed: %v", ensurerMethod, err)
+		return
+	}
+
+	diff, diffErr := o.diff(obj, old)
+	if diffErr != nil {
+		o.recorder.Eventf(obj, corev1.EventTypeNormal, "Mutated", "%s applied (diff unavailable: %v)", ensurerMethod, diffErr)
+		return
+	}
+
+	o.recorder.Eventf(obj, corev1.EventTypeNormal, "Mutated", "%s applied: %s", ensurerMethod, o.truncate(diff))
+}
+
+func (o *EventMutationObserver) diff(obj, old client.Object) (string, error) {
+	newSpec := specOf(obj)
+
+	newJSON, err := json.Marshal(newSpec)
+	if err != nil {
+		return "", err
+	}
+
+	if old == nil {
+		return string(newJSON), nil
+	}
+
+	oldJSON, err := json.Marshal(specOf(old))
+	if err != nil {
+		return "", err
+	}
+
+	patch, err := strategicpatch.CreateTwoWayMergePatch(oldJSON, newJSON, newSpec)
+	if err != nil {
+		if patch, err = createJSONMergePatch(oldJSON, newJSON); err != nil {
+			return "", err
+		}
+	}
+
+	return string(patch), nil
+}
+
+// specOf returns the Spec field of obj, if it has one, so diffs focus on what the ensurer actually changed
+// rather than unrelated ObjectMeta/Status/ManagedFields churn. Objects without a Spec field (e.g. a
+// ConfigMap, or an arbitrary object reached via EnsureObject) are diffed as a whole.
+func specOf(obj client.Object) interface{} {
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return obj
+	}
+
+	spec := v.FieldByName("Spec")
+	if !spec.IsValid() {
+		return obj
+	}
+
+	return spec.Interface()
+}
+
+// createJSONMergePatch computes an RFC 7396 JSON merge patch describing how to turn original into modified.
+// It is used as a fallback when strategicpatch.CreateTwoWayMergePatch cannot be applied (e.g. dataStruct has
+// no strategic merge patch tags), so the event still carries a compact diff instead of the full before/after.
+func createJSONMergePatch(original, modified []byte) ([]byte, error) {
+	var oldValue, newValue interface{}
+	if err := json.Unmarshal(original, &oldValue); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(modified, &newValue); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergePatch(oldValue, newValue))
+}
+
+// mergePatch computes the RFC 7396 merge patch value that turns old into new. Keys present in old but not in
+// new are represented with a nil value, the merge patch convention for removal.
+func mergePatch(old, new interface{}) interface{} {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if !oldIsMap || !newIsMap {
+		return new
+	}
+
+	patch := map[string]interface{}{}
+	for key, newVal := range newMap {
+		oldVal, existed := oldMap[key]
+		if !existed {
+			patch[key] = newVal
+			continue
+		}
+		if reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+
+		nested := mergePatch(oldVal, newVal)
+		if nestedMap, ok := nested.(map[string]interface{}); ok && len(nestedMap) == 0 {
+			continue
+		}
+		patch[key] = nested
+	}
+
+	for key := range oldMap {
+		if _, exists := newMap[key]; !exists {
+			patch[key] = nil
+		}
+	}
+
+	return patch
+}
+
+func (o *EventMutationObserver) truncate(s string) string {
+	if len(s) <= o.maxDiffBytes {
+		return s
+	}
+	return s[:o.maxDiffBytes] + "...(truncated)"
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericmutator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPrometheusMutationObserverRecordsSuccessAndError(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	observer, err := NewPrometheusMutationObserver(registry)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svc := &corev1.Service{}
+	svc.Name = "my-svc"
+	svc.Namespace = "my-ns"
+
+	observer.ObserveMutation(context.Background(), svc, nil, "EnsureKubeAPIServerService", time.Second, nil)
+	observer.ObserveMutation(context.Background(), svc, nil, "EnsureKubeAPIServerService", time.Second, errors.New("boom"))
+
+	if got := testutil.ToFloat64(observer.mutationsTotal.WithLabelValues("", "my-ns", "my-svc", "EnsureKubeAPIServerService", mutationResultSuccess)); got != 1 {
+		t.Fatalf("expected 1 success counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(observer.mutationsTotal.WithLabelValues("", "my-ns", "my-svc", "EnsureKubeAPIServerService", mutationResultError)); got != 1 {
+		t.Fatalf("expected 1 error counted, got %v", got)
+	}
+}
+
+func TestEventMutationObserverRecordsWarningOnError(t *testing.T) {
+	recorder := record.NewFakeRecorder(1)
+	observer := NewEventMutationObserver(recorder, 0)
+
+	svc := &corev1.Service{}
+	observer.ObserveMutation(context.Background(), svc, nil, "EnsureKubeAPIServerService", 0, errors.New("boom"))
+
+	event := <-recorder.Events
+	if !strings.Contains(event, "Warning") || !strings.Contains(event, "boom") {
+		t.Fatalf("expected a warning event mentioning the error, got %q", event)
+	}
+}
+
+func TestEventMutationObserverDiffCoversOnlySpec(t *testing.T) {
+	recorder := record.NewFakeRecorder(1)
+	observer := NewEventMutationObserver(recorder, 0)
+
+	oldSvc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "1.1.1.1"},
+	}
+	newSvc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "2.2.2.2"},
+	}
+
+	observer.ObserveMutation(context.Background(), newSvc, oldSvc, "EnsureKubeAPIServerService", 0, nil)
+
+	event := <-recorder.Events
+	if !strings.Contains(event, "2.2.2.2") {
+		t.Fatalf("expected diff to mention the changed spec field, got %q", event)
+	}
+	if strings.Contains(event, "resourceVersion") {
+		t.Fatalf("expected diff to exclude ObjectMeta churn such as resourceVersion, got %q", event)
+	}
+}
+
+func TestSpecOfReturnsSpecField(t *testing.T) {
+	svc := &corev1.Service{Spec: corev1.ServiceSpec{ClusterIP: "1.2.3.4"}}
+
+	spec, ok := specOf(svc).(corev1.ServiceSpec)
+	if !ok {
+		t.Fatalf("expected specOf to return a corev1.ServiceSpec, got %T", specOf(svc))
+	}
+	if spec.ClusterIP != "1.2.3.4" {
+		t.Fatalf("got ClusterIP %q, want %q", spec.ClusterIP, "1.2.3.4")
+	}
+}
+
+func TestSpecOfFallsBackToWholeObjectWithoutSpecField(t *testing.T) {
+	cm := &corev1.ConfigMap{Data: map[string]string{"k": "v"}}
+
+	if got := specOf(cm); got != client.Object(cm) {
+		t.Fatalf("expected specOf to fall back to the object itself, got %v", got)
+	}
+}
+
+func TestMergePatchProducesCompactDiff(t *testing.T) {
+	old := []byte(`{"a":"1","b":{"c":"2","d":"3"},"e":"unchanged"}`)
+	new := []byte(`{"a":"1","b":{"c":"4","d":"3"},"e":"unchanged"}`)
+
+	patch, err := createJSONMergePatch(old, new)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(string(patch), "unchanged") {
+		t.Fatalf("expected merge patch to omit unchanged fields, got %s", patch)
+	}
+	if !strings.Contains(string(patch), `"c":"4"`) {
+		t.Fatalf("expected merge patch to contain the changed nested field, got %s", patch)
+	}
+	if strings.Contains(string(patch), `"d":"3"`) {
+		t.Fatalf("expected merge patch to omit the unchanged nested field, got %s", patch)
+	}
+}
+
+func TestMergePatchRepresentsRemovedKeysAsNull(t *testing.T) {
+	old := []byte(`{"a":"1","b":"2"}`)
+	new := []byte(`{"a":"1"}`)
+
+	patch, err := createJSONMergePatch(old, new)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(string(patch), `"b":null`) {
+		t.Fatalf("expected removed key to be represented as null, got %s", patch)
+	}
+}
+
+func TestMultiMutationObserverFansOutToAllObservers(t *testing.T) {
+	var first, second int
+	a := mutationObserverFunc(func(context.Context, client.Object, client.Object, string, time.Duration, error) { first++ })
+	b := mutationObserverFunc(func(context.Context, client.Object, client.Object, string, time.Duration, error) { second++ })
+
+	multi := &multiMutationObserver{observers: []MutationObserver{a, b}}
+	multi.ObserveMutation(context.Background(), &corev1.Service{}, nil, "EnsureKubeAPIServerService", 0, nil)
+
+	if first != 1 || second != 1 {
+		t.Fatalf("expected both observers to be invoked once, got first=%d second=%d", first, second)
+	}
+}
+
+func TestWithMutationObserverComposesWithLogging(t *testing.T) {
+	var calls int
+	custom := mutationObserverFunc(func(context.Context, client.Object, client.Object, string, time.Duration, error) { calls++ })
+
+	m := NewMutator(NoopEnsurer{}, nil, nil, nil, logr.Discard(), WithMutationObserver(custom)).(*mutator)
+
+	multi, ok := m.observer.(*multiMutationObserver)
+	if !ok {
+		t.Fatalf("expected m.observer to be a *multiMutationObserver, got %T", m.observer)
+	}
+	if len(multi.observers) != 2 {
+		t.Fatalf("expected 2 composed observers, got %d", len(multi.observers))
+	}
+	if _, ok := multi.observers[0].(*loggingMutationObserver); !ok {
+		t.Fatalf("expected the logging observer to be preserved as the first observer, got %T", multi.observers[0])
+	}
+
+	multi.ObserveMutation(context.Background(), &corev1.Service{}, nil, "EnsureKubeAPIServerService", 0, nil)
+	if calls != 1 {
+		t.Fatalf("expected the custom observer to still be invoked, got %d calls", calls)
+	}
+}
+
+// mutationObserverFunc adapts a plain function to the MutationObserver interface for tests.
+type mutationObserverFunc func(ctx context.Context, obj, old client.Object, ensurerMethod string, duration time.Duration, err error)
+
+func (f mutationObserverFunc) ObserveMutation(ctx context.Context, obj, old client.Object, ensurerMethod string, duration time.Duration, err error) {
+	f(ctx, obj, old, ensurerMethod, duration, err)
+}
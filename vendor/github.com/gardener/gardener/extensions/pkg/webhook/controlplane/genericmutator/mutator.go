@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/coreos/go-systemd/v22/unit"
@@ -83,6 +84,58 @@ type Ensurer interface {
 	// EnsureAdditionalFiles ensures additional systemd files
 	// "old" might be "nil" and must always be checked.
 	EnsureAdditionalFiles(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *[]extensionsv1alpha1.File) error
+	// EnsureProvisionOSCFiles ensures additional systemd files for the OperatingSystemConfig with purpose
+	// Provision, i.e. files that must be present on first boot before the kubelet starts.
+	// "old" might be "nil" and must always be checked.
+	EnsureProvisionOSCFiles(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *[]extensionsv1alpha1.File) error
+	// EnsureProvisionOSCUnits ensures additional systemd units for the OperatingSystemConfig with purpose
+	// Provision, i.e. units that must be present on first boot before the kubelet starts.
+	// "old" might be "nil" and must always be checked.
+	EnsureProvisionOSCUnits(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old *[]extensionsv1alpha1.Unit) error
+	// EnsureDaemonSet ensures that the named daemonset conforms to the provider requirements.
+	// "old" might be "nil" and must always be checked.
+	EnsureDaemonSet(ctx context.Context, gctx extensionscontextwebhook.GardenContext, name string, new, old *appsv1.DaemonSet) error
+	// EnsureStatefulSet ensures that the named statefulset conforms to the provider requirements.
+	// "old" might be "nil" and must always be checked.
+	EnsureStatefulSet(ctx context.Context, gctx extensionscontextwebhook.GardenContext, name string, new, old *appsv1.StatefulSet) error
+	// EnsureObject is a generic fallback for objects whose type is not handled by any of the other Ensure* methods.
+	// Services, deployments, etcds, operating system configs, daemonsets and statefulsets never reach it, even if
+	// their name or purpose is not handled by another method.
+	// "old" might be "nil" and must always be checked.
+	EnsureObject(ctx context.Context, gctx extensionscontextwebhook.GardenContext, new, old client.Object) error
+}
+
+// MutatorOption can be passed to NewMutator to customize the returned mutator.
+type MutatorOption func(*mutator)
+
+// WithKubeletFlagPolicy configures the KubeletFlagPolicy that is applied to the kubelet.service unit's
+// ExecStart command line before it is handed to the Ensurer. It replaces the scattered, version-dependent
+// conditionals that provider ensurers would otherwise need to implement themselves.
+func WithKubeletFlagPolicy(policy KubeletFlagPolicy) MutatorOption {
+	return func(m *mutator) {
+		m.kubeletFlagPolicy = policy
+	}
+}
+
+// WithClaimedDaemonSets declares the names of the daemonsets that the Ensurer wants to mutate. DaemonSets
+// whose name was not claimed are passed through unmodified, without EnsureDaemonSet being called.
+func WithClaimedDaemonSets(names ...string) MutatorOption {
+	return func(m *mutator) {
+		for _, name := range names {
+			m.claimedDaemonSets[name] = struct{}{}
+		}
+	}
+}
+
+// WithClaimedStatefulSets declares the names of the statefulsets that the Ensurer wants to mutate.
+// StatefulSets whose name was not claimed are passed through unmodified, without EnsureStatefulSet being
+// called.
+func WithClaimedStatefulSets(names ...string) MutatorOption {
+	return func(m *mutator) {
+		for _, name := range names {
+			m.claimedStatefulSets[name] = struct{}{}
+		}
+	}
 }
 
 // NewMutator creates a new controlplane mutator.
@@ -92,23 +145,46 @@ func NewMutator(
 	kubeletConfigCodec kubelet.ConfigCodec,
 	fciCodec utils.FileContentInlineCodec,
 	logger logr.Logger,
+	opts ...MutatorOption,
 ) extensionswebhook.Mutator {
-	return &mutator{
-		ensurer:            ensurer,
-		unitSerializer:     unitSerializer,
-		kubeletConfigCodec: kubeletConfigCodec,
-		fciCodec:           fciCodec,
-		logger:             logger.WithName("mutator"),
+	m := &mutator{
+		ensurer:             ensurer,
+		unitSerializer:      unitSerializer,
+		kubeletConfigCodec:  kubeletConfigCodec,
+		fciCodec:            fciCodec,
+		logger:              logger.WithName("mutator"),
+		claimedDaemonSets:   map[string]struct{}{},
+		claimedStatefulSets: map[string]struct{}{},
+	}
+	m.observer = &loggingMutationObserver{mutator: m}
+
+	for _, opt := range opts {
+		opt(m)
 	}
+
+	return m
 }
 
 type mutator struct {
-	client             client.Client
-	ensurer            Ensurer
-	unitSerializer     utils.UnitSerializer
-	kubeletConfigCodec kubelet.ConfigCodec
-	fciCodec           utils.FileContentInlineCodec
-	logger             logr.Logger
+	client              client.Client
+	ensurer             Ensurer
+	unitSerializer      utils.UnitSerializer
+	kubeletConfigCodec  kubelet.ConfigCodec
+	fciCodec            utils.FileContentInlineCodec
+	kubeletFlagPolicy   KubeletFlagPolicy
+	claimedDaemonSets   map[string]struct{}
+	claimedStatefulSets map[string]struct{}
+	observer            MutationObserver
+	logger              logr.Logger
+}
+
+// observeMutation runs fn against obj, timing it and reporting the outcome to the configured
+// MutationObserver. It is the single place that replaces the previous extensionswebhook.LogMutation call.
+func (m *mutator) observeMutation(ctx context.Context, obj, old client.Object, ensurerMethod string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	m.observer.ObserveMutation(ctx, obj, old, ensurerMethod, time.Since(start), err)
+	return err
 }
 
 // InjectClient injects the given client into the ensurer.
@@ -143,8 +219,9 @@ func (m *mutator) Mutate(ctx context.Context, new, old client.Object) error {
 				}
 			}
 
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.ensurer.EnsureKubeAPIServerService(ctx, gctx, x, oldSvc)
+			return m.observeMutation(ctx, x, oldSvc, "EnsureKubeAPIServerService", func() error {
+				return m.ensurer.EnsureKubeAPIServerService(ctx, gctx, x, oldSvc)
+			})
 		}
 	case *appsv1.Deployment:
 		var oldDep *appsv1.Deployment
@@ -158,20 +235,25 @@ func (m *mutator) Mutate(ctx context.Context, new, old client.Object) error {
 
 		switch x.Name {
 		case v1beta1constants.DeploymentNameKubeAPIServer:
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.ensurer.EnsureKubeAPIServerDeployment(ctx, gctx, x, oldDep)
+			return m.observeMutation(ctx, x, oldDep, "EnsureKubeAPIServerDeployment", func() error {
+				return m.ensurer.EnsureKubeAPIServerDeployment(ctx, gctx, x, oldDep)
+			})
 		case v1beta1constants.DeploymentNameKubeControllerManager:
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.ensurer.EnsureKubeControllerManagerDeployment(ctx, gctx, x, oldDep)
+			return m.observeMutation(ctx, x, oldDep, "EnsureKubeControllerManagerDeployment", func() error {
+				return m.ensurer.EnsureKubeControllerManagerDeployment(ctx, gctx, x, oldDep)
+			})
 		case v1beta1constants.DeploymentNameKubeScheduler:
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.ensurer.EnsureKubeSchedulerDeployment(ctx, gctx, x, oldDep)
+			return m.observeMutation(ctx, x, oldDep, "EnsureKubeSchedulerDeployment", func() error {
+				return m.ensurer.EnsureKubeSchedulerDeployment(ctx, gctx, x, oldDep)
+			})
 		case v1beta1constants.DeploymentNameClusterAutoscaler:
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.ensurer.EnsureClusterAutoscalerDeployment(ctx, gctx, x, oldDep)
+			return m.observeMutation(ctx, x, oldDep, "EnsureClusterAutoscalerDeployment", func() error {
+				return m.ensurer.EnsureClusterAutoscalerDeployment(ctx, gctx, x, oldDep)
+			})
 		case v1beta1constants.DeploymentNameVPNSeedServer:
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.ensurer.EnsureVPNSeedServerDeployment(ctx, gctx, x, oldDep)
+			return m.observeMutation(ctx, x, oldDep, "EnsureVPNSeedServerDeployment", func() error {
+				return m.ensurer.EnsureVPNSeedServerDeployment(ctx, gctx, x, oldDep)
+			})
 		}
 	case *druidv1alpha1.Etcd:
 		switch x.Name {
@@ -185,24 +267,69 @@ func (m *mutator) Mutate(ctx context.Context, new, old client.Object) error {
 				}
 			}
 
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.ensurer.EnsureETCD(ctx, gctx, x, oldEtcd)
+			return m.observeMutation(ctx, x, oldEtcd, "EnsureETCD", func() error {
+				return m.ensurer.EnsureETCD(ctx, gctx, x, oldEtcd)
+			})
 		}
 	case *extensionsv1alpha1.OperatingSystemConfig:
-		if x.Spec.Purpose == extensionsv1alpha1.OperatingSystemConfigPurposeReconcile {
-			var oldOSC *extensionsv1alpha1.OperatingSystemConfig
-			if old != nil {
-				var ok bool
-				oldOSC, ok = old.(*extensionsv1alpha1.OperatingSystemConfig)
-				if !ok {
-					return errors.New("could not cast old object to extensionsv1alpha1.OperatingSystemConfig")
-				}
+		var oldOSC *extensionsv1alpha1.OperatingSystemConfig
+		if old != nil {
+			var ok bool
+			oldOSC, ok = old.(*extensionsv1alpha1.OperatingSystemConfig)
+			if !ok {
+				return errors.New("could not cast old object to extensionsv1alpha1.OperatingSystemConfig")
 			}
+		}
 
-			extensionswebhook.LogMutation(m.logger, x.Kind, x.Namespace, x.Name)
-			return m.mutateOperatingSystemConfig(ctx, gctx, x, oldOSC)
+		switch x.Spec.Purpose {
+		case extensionsv1alpha1.OperatingSystemConfigPurposeReconcile:
+			return m.observeMutation(ctx, x, oldOSC, "mutateOperatingSystemConfig", func() error {
+				return m.mutateOperatingSystemConfig(ctx, gctx, x, oldOSC)
+			})
+		case extensionsv1alpha1.OperatingSystemConfigPurposeProvision:
+			return m.observeMutation(ctx, x, oldOSC, "mutateProvisionOperatingSystemConfig", func() error {
+				return m.mutateProvisionOperatingSystemConfig(ctx, gctx, x, oldOSC)
+			})
 		}
 		return nil
+	case *appsv1.DaemonSet:
+		if _, claimed := m.claimedDaemonSets[x.Name]; !claimed {
+			return nil
+		}
+
+		var oldDS *appsv1.DaemonSet
+		if old != nil {
+			var ok bool
+			oldDS, ok = old.(*appsv1.DaemonSet)
+			if !ok {
+				return errors.New("could not cast old object to appsv1.DaemonSet")
+			}
+		}
+
+		return m.observeMutation(ctx, x, oldDS, "EnsureDaemonSet", func() error {
+			return m.ensurer.EnsureDaemonSet(ctx, gctx, x.Name, x, oldDS)
+		})
+	case *appsv1.StatefulSet:
+		if _, claimed := m.claimedStatefulSets[x.Name]; !claimed {
+			return nil
+		}
+
+		var oldSS *appsv1.StatefulSet
+		if old != nil {
+			var ok bool
+			oldSS, ok = old.(*appsv1.StatefulSet)
+			if !ok {
+				return errors.New("could not cast old object to appsv1.StatefulSet")
+			}
+		}
+
+		return m.observeMutation(ctx, x, oldSS, "EnsureStatefulSet", func() error {
+			return m.ensurer.EnsureStatefulSet(ctx, gctx, x.Name, x, oldSS)
+		})
+	default:
+		return m.observeMutation(ctx, new, old, "EnsureObject", func() error {
+			return m.ensurer.EnsureObject(ctx, gctx, new, old)
+		})
 	}
 	return nil
 }
@@ -235,16 +362,17 @@ func findFileWithPath(osc *extensionsv1alpha1.OperatingSystemConfig, path string
 	return nil
 }
 
-func (m *mutator) mutateOperatingSystemConfig(ctx context.Context, gctx extensionscontextwebhook.GardenContext, osc, oldOSC *extensionsv1alpha1.OperatingSystemConfig) error {
+// effectiveKubeletVersion calculates the effective kubelet version for the worker pool the given
+// OperatingSystemConfig belongs to.
+func effectiveKubeletVersion(ctx context.Context, gctx extensionscontextwebhook.GardenContext, osc *extensionsv1alpha1.OperatingSystemConfig) (*semver.Version, error) {
 	cluster, err := gctx.GetCluster(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Calculate effective kubelet version for the worker pool this OperatingSystemConfig belongs to
 	controlPlaneVersion, err := semver.NewVersion(cluster.Shoot.Spec.Kubernetes.Version)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var workerKubernetes *gardencorev1beta1.WorkerKubernetes
@@ -257,7 +385,33 @@ func (m *mutator) mutateOperatingSystemConfig(ctx context.Context, gctx extensio
 		}
 	}
 
-	kubeletVersion, err := v1beta1helper.CalculateEffectiveKubernetesVersion(controlPlaneVersion, workerKubernetes)
+	return v1beta1helper.CalculateEffectiveKubernetesVersion(controlPlaneVersion, workerKubernetes)
+}
+
+// mutateProvisionOperatingSystemConfig mutates files and units of an OperatingSystemConfig with purpose
+// Provision. Unlike the Reconcile purpose, these files and units are applied at bootstrap time, before the
+// kubelet starts, so they cannot be injected via mutateOperatingSystemConfig.
+func (m *mutator) mutateProvisionOperatingSystemConfig(ctx context.Context, gctx extensionscontextwebhook.GardenContext, osc, oldOSC *extensionsv1alpha1.OperatingSystemConfig) error {
+	var (
+		oldFiles *[]extensionsv1alpha1.File
+		oldUnits *[]extensionsv1alpha1.Unit
+	)
+
+	if oldOSC != nil {
+		oldFiles = &oldOSC.Spec.Files
+		oldUnits = &oldOSC.Spec.Units
+	}
+
+	if err := m.ensurer.EnsureProvisionOSCFiles(ctx, gctx, &osc.Spec.Files, oldFiles); err != nil {
+		return err
+	}
+
+	return m.ensurer.EnsureProvisionOSCUnits(ctx, gctx, &osc.Spec.Units, oldUnits)
+}
+
+func (m *mutator) mutateOperatingSystemConfig(ctx context.Context, gctx extensionscontextwebhook.GardenContext, osc, oldOSC *extensionsv1alpha1.OperatingSystemConfig) error {
+	// Calculate effective kubelet version for the worker pool this OperatingSystemConfig belongs to
+	kubeletVersion, err := effectiveKubeletVersion(ctx, gctx, osc)
 	if err != nil {
 		return err
 	}
@@ -325,6 +479,11 @@ func (m *mutator) ensureKubeletServiceUnitContent(ctx context.Context, gctx exte
 		}
 	}
 
+	// Drop or rewrite flags that are no longer valid for this kubelet version before the ensurer ever sees them.
+	if opts, err = m.kubeletFlagPolicy.apply(kubeletVersion, opts); err != nil {
+		return err
+	}
+
 	if opts, err = m.ensurer.EnsureKubeletServiceUnitOptions(ctx, gctx, kubeletVersion, opts, oldOpts); err != nil {
 		return err
 	}
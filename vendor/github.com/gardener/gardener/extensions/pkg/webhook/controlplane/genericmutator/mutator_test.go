@@ -0,0 +1,172 @@
+// Copyright (c) 2020 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genericmutator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	extensionscontextwebhook "github.com/gardener/gardener/extensions/pkg/webhook/context"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+)
+
+// recordingMutateEnsurer records which Ensure* method was invoked and on which object name, so tests can
+// assert on Mutate's routing without a real client or cluster.
+type recordingMutateEnsurer struct {
+	NoopEnsurer
+	calls []string
+}
+
+func (e *recordingMutateEnsurer) EnsureDaemonSet(_ context.Context, _ extensionscontextwebhook.GardenContext, name string, _, _ *appsv1.DaemonSet) error {
+	e.calls = append(e.calls, "EnsureDaemonSet:"+name)
+	return nil
+}
+
+func (e *recordingMutateEnsurer) EnsureStatefulSet(_ context.Context, _ extensionscontextwebhook.GardenContext, name string, _, _ *appsv1.StatefulSet) error {
+	e.calls = append(e.calls, "EnsureStatefulSet:"+name)
+	return nil
+}
+
+func (e *recordingMutateEnsurer) EnsureObject(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ client.Object) error {
+	e.calls = append(e.calls, "EnsureObject")
+	return nil
+}
+
+func (e *recordingMutateEnsurer) EnsureProvisionOSCFiles(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *[]extensionsv1alpha1.File) error {
+	e.calls = append(e.calls, "EnsureProvisionOSCFiles")
+	return nil
+}
+
+func (e *recordingMutateEnsurer) EnsureProvisionOSCUnits(_ context.Context, _ extensionscontextwebhook.GardenContext, _, _ *[]extensionsv1alpha1.Unit) error {
+	e.calls = append(e.calls, "EnsureProvisionOSCUnits")
+	return nil
+}
+
+func newTestMutator(ensurer Ensurer, opts ...MutatorOption) *mutator {
+	m := NewMutator(ensurer, nil, nil, nil, logr.Discard(), opts...)
+	return m.(*mutator)
+}
+
+func TestMutateIgnoresUnclaimedDaemonSet(t *testing.T) {
+	ensurer := &recordingMutateEnsurer{}
+	m := newTestMutator(ensurer, WithClaimedDaemonSets("claimed"))
+
+	ds := &appsv1.DaemonSet{}
+	ds.Name = "unclaimed"
+
+	if err := m.Mutate(context.Background(), ds, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ensurer.calls) != 0 {
+		t.Fatalf("expected no ensurer calls for unclaimed daemonset, got %v", ensurer.calls)
+	}
+}
+
+func TestMutateRoutesClaimedDaemonSet(t *testing.T) {
+	ensurer := &recordingMutateEnsurer{}
+	m := newTestMutator(ensurer, WithClaimedDaemonSets("claimed"))
+
+	ds := &appsv1.DaemonSet{}
+	ds.Name = "claimed"
+
+	if err := m.Mutate(context.Background(), ds, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"EnsureDaemonSet:claimed"}
+	if len(ensurer.calls) != 1 || ensurer.calls[0] != want[0] {
+		t.Fatalf("got calls %v, want %v", ensurer.calls, want)
+	}
+}
+
+func TestMutateIgnoresUnclaimedStatefulSet(t *testing.T) {
+	ensurer := &recordingMutateEnsurer{}
+	m := newTestMutator(ensurer, WithClaimedStatefulSets("claimed"))
+
+	ss := &appsv1.StatefulSet{}
+	ss.Name = "unclaimed"
+
+	if err := m.Mutate(context.Background(), ss, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ensurer.calls) != 0 {
+		t.Fatalf("expected no ensurer calls for unclaimed statefulset, got %v", ensurer.calls)
+	}
+}
+
+func TestMutateRoutesClaimedStatefulSet(t *testing.T) {
+	ensurer := &recordingMutateEnsurer{}
+	m := newTestMutator(ensurer, WithClaimedStatefulSets("claimed"))
+
+	ss := &appsv1.StatefulSet{}
+	ss.Name = "claimed"
+
+	if err := m.Mutate(context.Background(), ss, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"EnsureStatefulSet:claimed"}
+	if len(ensurer.calls) != 1 || ensurer.calls[0] != want[0] {
+		t.Fatalf("got calls %v, want %v", ensurer.calls, want)
+	}
+}
+
+func TestMutateFallsBackToEnsureObjectForUnknownType(t *testing.T) {
+	ensurer := &recordingMutateEnsurer{}
+	m := newTestMutator(ensurer)
+
+	cm := &corev1.ConfigMap{}
+
+	if err := m.Mutate(context.Background(), cm, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"EnsureObject"}
+	if len(ensurer.calls) != 1 || ensurer.calls[0] != want[0] {
+		t.Fatalf("got calls %v, want %v", ensurer.calls, want)
+	}
+}
+
+func TestMutateRoutesProvisionOperatingSystemConfigWithoutClusterLookup(t *testing.T) {
+	ensurer := &recordingMutateEnsurer{}
+	m := newTestMutator(ensurer)
+
+	osc := &extensionsv1alpha1.OperatingSystemConfig{
+		Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+			Purpose: extensionsv1alpha1.OperatingSystemConfigPurposeProvision,
+		},
+	}
+
+	// No client was injected, so this would panic/fail if mutateProvisionOperatingSystemConfig still tried
+	// to resolve the effective kubelet version via the cluster.
+	if err := m.Mutate(context.Background(), osc, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"EnsureProvisionOSCFiles", "EnsureProvisionOSCUnits"}
+	if len(ensurer.calls) != len(want) {
+		t.Fatalf("got calls %v, want %v", ensurer.calls, want)
+	}
+	for i := range want {
+		if ensurer.calls[i] != want[i] {
+			t.Fatalf("got calls %v, want %v", ensurer.calls, want)
+		}
+	}
+}